@@ -0,0 +1,113 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Velocity Installer, a cross platform gui/cli app for installing Velocity
+ * Copyright (c) 2025 Velocitcs and Velocity contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+// ReleaseTrack selects which GitHub releases are eligible for install/update. The
+// track is matched against a release's tag_name suffix by SelectReleaseForTrack.
+type ReleaseTrack string
+
+const (
+	TrackStable  ReleaseTrack = "stable"
+	TrackCanary  ReleaseTrack = "canary"
+	TrackNightly ReleaseTrack = "nightly"
+)
+
+// TrackSuffixes maps a track to the tag_name suffix that identifies it. Stable has
+// no suffix: any release not tagged for another track is treated as stable.
+var TrackSuffixes = map[ReleaseTrack]string{
+	TrackStable:  "",
+	TrackCanary:  "-canary",
+	TrackNightly: "-nightly",
+}
+
+// InstallerConfig is persisted to configPath() and controls which installer and
+// Velocity releases are eligible for install/update. The two are tracked
+// independently so a user can, for example, run the stable installer against a
+// canary Velocity build.
+type InstallerConfig struct {
+	UpdateTrack           ReleaseTrack `json:"updateTrack"`
+	PinnedVersion         string       `json:"pinnedVersion,omitempty"`
+	VelocityTrack         ReleaseTrack `json:"velocityTrack"`
+	PinnedVelocityVersion string       `json:"pinnedVelocityVersion,omitempty"`
+}
+
+var Config = InstallerConfig{UpdateTrack: TrackStable, VelocityTrack: TrackStable}
+
+var (
+	updateTrackFlag     = flag.String("update-track", "", "installer release track to follow (stable, canary, nightly)")
+	updateVersionFlag   = flag.String("update-version", "", "pin the installer to an exact release tag, bypassing --update-track")
+	velocityTrackFlag   = flag.String("velocity-track", "", "Velocity release track to follow (stable, canary, nightly)")
+	velocityVersionFlag = flag.String("velocity-version", "", "pin Velocity to an exact release tag, bypassing --velocity-track")
+)
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "VelocityInstaller", "config.json"), nil
+}
+
+// LoadConfig reads the persisted installer config and applies the --update-track,
+// --update-version, --velocity-track and --velocity-version overrides on top. It
+// must run after flag.Parse().
+func LoadConfig() {
+	if path, err := configPath(); err == nil {
+		if b, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(b, &Config)
+		}
+	}
+
+	if *updateTrackFlag != "" {
+		Config.UpdateTrack = ReleaseTrack(*updateTrackFlag)
+	}
+	if *updateVersionFlag != "" {
+		Config.PinnedVersion = *updateVersionFlag
+	}
+	if *velocityTrackFlag != "" {
+		Config.VelocityTrack = ReleaseTrack(*velocityTrackFlag)
+	}
+	if *velocityVersionFlag != "" {
+		Config.PinnedVelocityVersion = *velocityVersionFlag
+	}
+
+	if _, ok := TrackSuffixes[Config.UpdateTrack]; !ok {
+		Log.Warn("Unknown update track, falling back to stable:", Config.UpdateTrack)
+		Config.UpdateTrack = TrackStable
+	}
+	if _, ok := TrackSuffixes[Config.VelocityTrack]; !ok {
+		Log.Warn("Unknown Velocity track, falling back to stable:", Config.VelocityTrack)
+		Config.VelocityTrack = TrackStable
+	}
+}
+
+// SaveConfig persists the current config to disk, creating its parent directory
+// if needed. Used by the GUI track/version dropdowns.
+func SaveConfig() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(Config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0644)
+}
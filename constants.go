@@ -11,8 +11,8 @@ import (
     "velocityinstaller/buildinfo"
 )
 
-const ReleaseUrl = "https://api.github.com/repos/Velocitcs/Velocity/releases/latest"
-const InstallerReleaseUrl = "https://api.github.com/repos/Velocitcy/Installer/releases/latest"
+const ReleaseUrl = "https://api.github.com/repos/Velocitcs/Velocity/releases"
+const InstallerReleaseUrl = "https://api.github.com/repos/Velocitcy/Installer/releases"
 
 var UserAgent = "VelocityInstaller/" + buildinfo.InstallerGitHash + " (https://github.com/Velocitcy/Installer)"
 
@@ -0,0 +1,338 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Velocity Installer, a cross platform gui/cli app for installing Velocity
+ * Copyright (c) 2025 Velocitcs and Velocity contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	path "path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DownloadProgress reports how far a single asset download has gotten. The CLI
+// renders it as a single rewritten status line; the GUI renders one progress
+// bar per asset.
+type DownloadProgress struct {
+	AssetName  string
+	BytesDone  int64
+	BytesTotal int64
+}
+
+// DownloadProgressChan publishes a DownloadProgress update as each tracked
+// download is read. Sends are non-blocking, so a slow or absent consumer never
+// stalls the download itself.
+var DownloadProgressChan = make(chan DownloadProgress, 64)
+
+func reportProgress(assetName string, done, total int64) {
+	select {
+	case DownloadProgressChan <- DownloadProgress{AssetName: assetName, BytesDone: done, BytesTotal: total}:
+	default:
+	}
+}
+
+// progressReader wraps src, reporting cumulative bytes read under assetName as
+// it is consumed.
+type progressReader struct {
+	src       io.Reader
+	assetName string
+	total     int64
+	done      int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.src.Read(b)
+	p.done += int64(n)
+	reportProgress(p.assetName, p.done, p.total)
+	return n, err
+}
+
+// httpCacheEntry is a URL's last known conditional-request validators. Body is
+// only populated for cache users that need to serve a 304 without redownloading
+// (e.g. GetGithubReleases); asset downloads leave it empty since the file itself
+// is already on disk.
+type httpCacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Body         []byte `json:"body,omitempty"`
+}
+
+type httpCache map[string]httpCacheEntry
+
+// cacheStore is an httpCache safe for concurrent use by the asset worker pool.
+type cacheStore struct {
+	mu    sync.Mutex
+	cache httpCache
+}
+
+func newCacheStore(cache httpCache) *cacheStore {
+	if cache == nil {
+		cache = httpCache{}
+	}
+	return &cacheStore{cache: cache}
+}
+
+func loadCacheStore(path string) *cacheStore {
+	cache := httpCache{}
+	if b, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(b, &cache)
+	}
+	return newCacheStore(cache)
+}
+
+func (c *cacheStore) entry(key string) (httpCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	return e, ok
+}
+
+func (c *cacheStore) set(key string, e httpCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache[key] = e
+}
+
+func (c *cacheStore) save(cachePath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(path.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(c.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(cachePath, b, 0644)
+}
+
+func assetCachePath(distDir string) string {
+	return path.Join(distDir, ".velocity-cache.json")
+}
+
+// withRetry runs do up to 3 times, retrying 5xx responses and transient network
+// errors with exponential backoff (500ms, then 1s) plus jitter.
+func withRetry(do func() (*http.Response, error)) (*http.Response, error) {
+	const attempts = 3
+	backoff := 500 * time.Millisecond
+
+	var res *http.Response
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		res, err = do()
+
+		retryable := err != nil || (res != nil && res.StatusCode >= 500)
+		if !retryable || attempt == attempts-1 {
+			break
+		}
+
+		if res != nil {
+			res.Body.Close()
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(backoff) + 1))
+		time.Sleep(backoff + jitter)
+		backoff *= 2
+	}
+
+	return res, err
+}
+
+// conditionalStream issues a GET against url, sending If-None-Match /
+// If-Modified-Since from store[cacheKey] if present, and records the response's
+// validators back into store. The caller owns and must close the returned
+// response's body, including on a 304 (empty body, nothing to skip closing).
+func conditionalStream(store *cacheStore, url, cacheKey string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	if entry, ok := store.entry(cacheKey); ok {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := withRetry(func() (*http.Response, error) {
+		return http.DefaultClient.Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusNotModified {
+		store.set(cacheKey, httpCacheEntry{
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+		})
+	}
+
+	return res, nil
+}
+
+// conditionalGet is conditionalStream for small JSON payloads: on a 304 it
+// returns the body cached from the last non-304 response instead of an empty
+// one, so repeated polls of an unchanged release avoid both the download and
+// GitHub's anonymous rate limit.
+func conditionalGet(store *cacheStore, url, cacheKey string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	if entry, ok := store.entry(cacheKey); ok {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := withRetry(func() (*http.Response, error) {
+		return http.DefaultClient.Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		if entry, ok := store.entry(cacheKey); ok && entry.Body != nil {
+			return entry.Body, nil
+		}
+	}
+
+	if res.StatusCode >= 300 {
+		return nil, errors.New(res.Status)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	store.set(cacheKey, httpCacheEntry{
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Body:         b,
+	})
+
+	return b, nil
+}
+
+// downloadAssetsConcurrently downloads every asset in assets into destDir using
+// a pool of runtime.NumCPU() workers, retrying transient failures and skipping
+// the download for any asset GitHub reports unchanged (304) via cache, instead
+// copying it forward from oldDir. The first asset to fail checksum verification
+// or exhaust its retries aborts the whole batch.
+func downloadAssetsConcurrently(destDir, oldDir string, assets []GithubAsset, manifest ReleaseManifest, cache *cacheStore) error {
+	g := new(errgroup.Group)
+	sem := make(chan struct{}, runtime.NumCPU())
+
+	for _, ass := range assets {
+		ass := ass
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			return downloadAsset(destDir, oldDir, ass, manifest, cache)
+		})
+	}
+
+	return g.Wait()
+}
+
+// downloadAsset downloads ass into destDir, unless GitHub reports it unchanged
+// (304), in which case it copies the already-verified copy from oldDir instead
+// — destDir is always a fresh stage directory, so skipping the copy too would
+// leave the asset missing from the staged install entirely.
+func downloadAsset(destDir, oldDir string, ass GithubAsset, manifest ReleaseManifest, cache *cacheStore) error {
+	Log.Debug("Downloading", ass.Name)
+
+	res, err := conditionalStream(cache, ass.DownloadURL, ass.Name)
+	if err != nil {
+		Log.Error("Failed to download "+ass.Name+":", err)
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		Log.Debug(ass.Name, "is unchanged, copying forward from existing install")
+		return copyExistingAsset(oldDir, destDir, ass.Name)
+	}
+	if res.StatusCode >= 300 {
+		err = errors.New(res.Status)
+		Log.Error("Failed to download "+ass.Name+":", err)
+		return err
+	}
+
+	filePath := path.Join(destDir, ass.Name)
+	out, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		Log.Error("Failed to create "+ass.Name+":", err)
+		return err
+	}
+	defer out.Close()
+
+	tracked := &progressReader{src: res.Body, assetName: ass.Name, total: res.ContentLength}
+
+	hash, err := hashingCopy(out, tracked)
+	if err != nil {
+		Log.Error("Failed to write "+ass.Name+":", err)
+		return err
+	}
+
+	if err = VerifyChecksum(manifest, ass.Name, hash); err != nil {
+		Log.Error("Checksum verification failed for "+ass.Name+":", err)
+		return err
+	}
+
+	_ = FixOwnership(filePath)
+	return nil
+}
+
+// copyExistingAsset copies name from oldDir into destDir, for an asset whose
+// download was skipped on a 304. Missing from oldDir (e.g. a first-ever
+// install reporting unchanged, which the ETag cache being fresh should make
+// impossible) is surfaced as an error rather than silently leaving destDir
+// without the asset.
+func copyExistingAsset(oldDir, destDir, name string) error {
+	src, err := os.Open(path.Join(oldDir, name))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path.Join(destDir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		return err
+	}
+
+	return FixOwnership(path.Join(destDir, name))
+}
@@ -9,67 +9,140 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
 	"os"
 	path "path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
+type GithubAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
 type GithubRelease struct {
-	Name    string `json:"name"`
-	TagName string `json:"tag_name"`
-	Assets  []struct {
-		Name        string `json:"name"`
-		DownloadURL string `json:"browser_download_url"`
-	} `json:"assets"`
+	Name        string        `json:"name"`
+	TagName     string        `json:"tag_name"`
+	PublishedAt string        `json:"published_at"`
+	Assets      []GithubAsset `json:"assets"`
+}
+
+// PublishedTime parses PublishedAt, GitHub's RFC3339 release timestamp.
+func (r *GithubRelease) PublishedTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, r.PublishedAt)
 }
 
-var ReleaseData GithubRelease
+// GithubError and GithubDoneChan describe the single Velocity release
+// check currently in flight, not any one Installation: callers must await
+// GithubDoneChan before starting another installation's check. The fetched
+// release and hash themselves live on the *Installation passed in
+// (Installation.latestRelease/latestHash), so independent installations can
+// each show their own installed-vs-latest status without clobbering one
+// another.
 var GithubError error
 var GithubDoneChan chan bool
 
-var InstalledHash = "None"
-var LatestHash = "Unknown"
 var IsDevInstall bool
 
-func GetGithubRelease(url string) (*GithubRelease, error) {
+// releasePollCachePath returns where releasePollCache is persisted, alongside
+// config.json and installations.json. "" if os.UserConfigDir is unavailable,
+// in which case the cache is kept in-memory for the process only.
+func releasePollCachePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(dir, "VelocityInstaller", "release-cache.json")
+}
+
+// releasePollCache lets rapid, repeated GetGithubReleases polls (e.g. the
+// self-updater's periodic check, or InitGithubDownloader's Velocity check) ride
+// a GitHub 304 instead of spending one of the 60/hr anonymous rate limit
+// requests. Persisted to disk so the budget is shared across launches too, not
+// just within one running process.
+var releasePollCache = loadCacheStore(releasePollCachePath())
+
+// GetGithubReleases fetches every release from a GitHub "releases" list endpoint
+// (newest first), so callers can pick a release by track or by exact tag instead
+// of always taking "latest". Repeated calls for the same url ride releasePollCache.
+func GetGithubReleases(url string) ([]GithubRelease, error) {
 	Log.Debug("Fetching", url)
 
-	req, err := http.NewRequest("GET", url, nil)
+	b, err := conditionalGet(releasePollCache, url, url)
 	if err != nil {
-		Log.Error("Failed to create Request", err)
+		Log.Error("Failed to fetch", url, err)
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", UserAgent)
+	if cachePath := releasePollCachePath(); cachePath != "" {
+		if err = releasePollCache.save(cachePath); err != nil {
+			Log.Warn("Failed to persist release poll cache:", err)
+		}
+	}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		Log.Error("Failed to send Request", err)
+	var data []GithubRelease
+	if err = json.Unmarshal(b, &data); err != nil {
+		Log.Error("Failed to decode GitHub JSON Response", err)
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	return data, nil
+}
 
-	if res.StatusCode >= 300 {
-		err = errors.New(res.Status)
-		Log.Error(url, "returned Non-OK status", err)
-		return nil, err
+// SelectReleaseForTrack returns the release for pinnedTag if set, otherwise the
+// newest release whose tag_name matches track's suffix. Releases are expected
+// newest-first, as returned by the GitHub releases list endpoint.
+func SelectReleaseForTrack(releases []GithubRelease, track ReleaseTrack, pinnedTag string) *GithubRelease {
+	if pinnedTag != "" {
+		return findReleaseByTag(releases, pinnedTag)
 	}
 
-	var data GithubRelease
+	suffix := TrackSuffixes[track]
 
-	if err = json.NewDecoder(res.Body).Decode(&data); err != nil {
-		Log.Error("Failed to decode GitHub JSON Response", err)
-		return nil, err
+	for i := range releases {
+		isTrackTagged := strings.Contains(releases[i].TagName, "-canary") || strings.Contains(releases[i].TagName, "-nightly")
+
+		if suffix == "" {
+			if !isTrackTagged {
+				return &releases[i]
+			}
+			continue
+		}
+
+		if strings.HasSuffix(releases[i].TagName, suffix) {
+			return &releases[i]
+		}
 	}
 
-	return &data, nil
+	return nil
 }
 
-func InitGithubDownloader() {
+func findReleaseByTag(releases []GithubRelease, tag string) *GithubRelease {
+	for i := range releases {
+		if releases[i].TagName == tag {
+			return &releases[i]
+		}
+	}
+	return nil
+}
+
+func findAsset(release *GithubRelease, name string) *GithubAsset {
+	for i := range release.Assets {
+		if release.Assets[i].Name == name {
+			return &release.Assets[i]
+		}
+	}
+	return nil
+}
+
+// InitGithubDownloader fetches the Velocity release for inst's branch track and
+// checks inst's existing hash on disk. Each Installation tracks its own state, so
+// checking one Discord variant never clobbers what's known about another.
+func InitGithubDownloader(inst *Installation) {
+	GithubError = nil
 	GithubDoneChan = make(chan bool, 1)
 
 	IsDevInstall = os.Getenv("VELOCITY_DEV_INSTALL") == "1"
@@ -85,31 +158,30 @@ func InitGithubDownloader() {
 			GithubDoneChan <- GithubError == nil
 		}()
 
-		data, err := GetGithubRelease(ReleaseUrl)
+		releases, err := GetGithubReleases(ReleaseUrl)
 		if err != nil {
 			GithubError = err
 			return
 		}
 
-		ReleaseData = *data
+		data := SelectReleaseForTrack(releases, inst.BranchTrack, Config.PinnedVelocityVersion)
+		if data == nil {
+			GithubError = fmt.Errorf("no Velocity release found for track %q", inst.BranchTrack)
+			return
+		}
+
+		inst.latestRelease = data
 
 		i := strings.LastIndex(data.Name, " ") + 1
-		LatestHash = data.Name[i:]
+		inst.latestHash = data.Name[i:]
 
 		Log.Debug("Finished fetching GitHub Data")
-		Log.Debug("Latest hash is", LatestHash, "Local Install is", Ternary(LatestHash == InstalledHash, "up to date!", "outdated!"))
+		Log.Debug("Latest hash is", inst.latestHash, "Local Install is", Ternary(inst.latestHash == inst.InstalledHash, "up to date!", "outdated!"))
 	}()
 
-	VelocityFile := VelocityDirectory
-
-	stat, err := os.Stat(VelocityFile)
-	if err != nil {
-		return
-	}
-
-	if stat.IsDir() {
-		VelocityFile = path.Join(VelocityFile, "main.js")
-	}
+	// inst.Path is the resources/app directory Velocity patches main.js into; it
+	// won't exist until the first install.
+	VelocityFile := path.Join(inst.Path, "main.js")
 
 	b, err := os.ReadFile(VelocityFile)
 	if err != nil {
@@ -121,15 +193,17 @@ func InitGithubDownloader() {
 	re := regexp.MustCompile(`// Velocity (\w+)`)
 	match := re.FindSubmatch(b)
 	if match != nil {
-		InstalledHash = string(match[1])
-		Log.Debug("Existing hash is", InstalledHash)
+		inst.InstalledHash = string(match[1])
+		Log.Debug("Existing hash is", inst.InstalledHash)
 	} else {
 		Log.Debug("Did not find hash")
 	}
 }
 
-func installLatestBuilds() (retErr error) {
-	Log.Debug("Installing latest builds...")
+// installLatestBuilds installs the release fetched by InitGithubDownloader into
+// inst, then updates and persists inst.InstalledHash on success.
+func installLatestBuilds(inst *Installation) (retErr error) {
+	Log.Debug("Installing latest builds into", inst.Path)
 
 	if IsDevInstall {
 		Log.Debug("Skipping due to dev install")
@@ -137,15 +211,150 @@ func installLatestBuilds() (retErr error) {
 	}
 
 	// Create parent directories
-	if err := os.MkdirAll(path.Dir(VelocityDirectory), 0755); err != nil {
+	if err := os.MkdirAll(path.Dir(inst.Path), 0755); err != nil {
 		Log.Error("Failed to create directories:", err)
 		retErr = err
 		return
 	}
 
-	distDir := path.Dir(VelocityDirectory)
+	// inst.Path is the resources/app directory itself, not main.js or its parent.
+	distDir := inst.Path
+
+	if inst.latestRelease == nil {
+		retErr = errors.New("no release fetched for this installation; call InitGithubDownloader first")
+		return
+	}
+
+	// FetchReleaseManifest runs before the archive-vs-loose branch below, so a
+	// release old enough to predate the signed manifest entirely (not just the
+	// archive asset) never reaches installFromLooseAssets: it's rejected here
+	// with ErrManifestAssetMissing. installFromLooseAssets only ever runs for
+	// the narrower case of a release that carries a manifest but no archive.
+	manifest, err := FetchReleaseManifest(inst.latestRelease)
+	if err != nil {
+		Log.Error("Failed to fetch release manifest:", err)
+		retErr = err
+		return
+	}
+
+	if archiveAsset := findAsset(inst.latestRelease, ArchiveAssetName()); archiveAsset != nil {
+		retErr = installFromArchive(distDir, manifest, archiveAsset)
+	} else {
+		Log.Warn("Release has no", ArchiveAssetName(), "asset, falling back to the per-asset install path")
+		retErr = installFromLooseAssets(distDir, inst.latestRelease, manifest)
+	}
+
+	if retErr == nil {
+		inst.InstalledHash = inst.latestHash
+		retErr = SaveInstallations()
+	}
+	return
+}
+
+// installFromArchive downloads the single packaged dist archive (skipping the
+// download entirely if the asset cache's ETag still matches), verifies it
+// against manifest, extracts it into a sibling "<distDir>.new" directory, and
+// swaps it in atomically. A failure at any step leaves distDir untouched.
+func installFromArchive(distDir string, manifest ReleaseManifest, archiveAsset *GithubAsset) error {
+	stageDir := distDir + ".new"
+	backupDir := distDir + ".old"
+
+	cache := loadCacheStore(assetCachePath(distDir))
+
+	Log.Debug("Downloading", archiveAsset.Name)
+
+	res, err := conditionalStream(cache, archiveAsset.DownloadURL, archiveAsset.Name)
+	if err != nil {
+		Log.Error("Failed to download "+archiveAsset.Name+":", err)
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		Log.Debug(archiveAsset.Name, "is unchanged, skipping archive install")
+		return nil
+	}
+	if res.StatusCode >= 300 {
+		err = errors.New(res.Status)
+		Log.Error("Failed to download "+archiveAsset.Name+":", err)
+		return err
+	}
+
+	tmpArchive, err := os.CreateTemp(path.Dir(distDir), "velocity-dist-*"+path.Ext(archiveAsset.Name))
+	if err != nil {
+		return fmt.Errorf("failed to create temp archive file: %w", err)
+	}
+	defer func() {
+		_ = tmpArchive.Close()
+		_ = os.Remove(tmpArchive.Name())
+	}()
+
+	tracked := &progressReader{src: res.Body, assetName: archiveAsset.Name, total: res.ContentLength}
+
+	hash, err := hashingCopy(tmpArchive, tracked)
+	if err != nil {
+		Log.Error("Failed to write "+archiveAsset.Name+":", err)
+		return err
+	}
+	if err = tmpArchive.Close(); err != nil {
+		return err
+	}
+
+	if err = VerifyChecksum(manifest, archiveAsset.Name, hash); err != nil {
+		Log.Error("Checksum verification failed for "+archiveAsset.Name+":", err)
+		return err
+	}
+
+	if err = os.RemoveAll(stageDir); err != nil {
+		Log.Error("Failed to clear stage directory:", err)
+		return err
+	}
+	if err = os.MkdirAll(stageDir, 0755); err != nil {
+		Log.Error("Failed to create stage directory:", err)
+		return err
+	}
+	defer os.RemoveAll(stageDir)
 
-	for _, ass := range ReleaseData.Assets {
+	if err = ExtractArchive(tmpArchive.Name(), stageDir); err != nil {
+		Log.Error("Failed to extract "+archiveAsset.Name+":", err)
+		return err
+	}
+
+	_ = FixOwnership(stageDir)
+
+	if err = swapInstall(distDir, stageDir, backupDir); err != nil {
+		return err
+	}
+
+	if err = cache.save(assetCachePath(distDir)); err != nil {
+		Log.Warn("Failed to persist asset cache:", err)
+	}
+
+	return nil
+}
+
+// installFromLooseAssets is the deprecated per-asset install path, kept for
+// releases published before the single archive asset existed but after the
+// signed manifest was introduced — installLatestBuilds requires a manifest
+// before reaching here, so this never serves a release old enough to predate
+// that too. Matching assets download concurrently through a bounded worker
+// pool; each is independently retried, progress-tracked and checksum-verified.
+func installFromLooseAssets(distDir string, release *GithubRelease, manifest ReleaseManifest) error {
+	stageDir := distDir + ".new"
+	backupDir := distDir + ".old"
+
+	if err := os.RemoveAll(stageDir); err != nil {
+		Log.Error("Failed to clear stage directory:", err)
+		return err
+	}
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		Log.Error("Failed to create stage directory:", err)
+		return err
+	}
+	defer os.RemoveAll(stageDir)
+
+	var wanted []GithubAsset
+	for _, ass := range release.Assets {
 		lowerName := strings.ToLower(ass.Name)
 
 		// Skip .LEGAL.txt files
@@ -159,39 +368,41 @@ func installLatestBuilds() (retErr error) {
 			strings.Contains(lowerName, "velocitydesktopMain") ||
 			strings.Contains(lowerName, "velocitydesktoprenderer") ||
 			(strings.Contains(lowerName, "renderer") && !strings.Contains(lowerName, "velocitydesktop")) {
+			wanted = append(wanted, ass)
+		}
+	}
 
-			Log.Debug("Downloading", ass.Name)
-
-			res, err := http.Get(ass.DownloadURL)
-			if err == nil && res.StatusCode >= 300 {
-				err = errors.New(res.Status)
-			}
-			if err != nil {
-				Log.Error("Failed to download "+ass.Name+":", err)
-				retErr = err
-				return
-			}
+	cache := loadCacheStore(assetCachePath(distDir))
 
-			filePath := path.Join(distDir, ass.Name)
-			out, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-			if err != nil {
-				Log.Error("Failed to create "+ass.Name+":", err)
-				retErr = err
-				return
-			}
+	if err := downloadAssetsConcurrently(stageDir, distDir, wanted, manifest, cache); err != nil {
+		return err
+	}
 
-			if _, err = io.Copy(out, res.Body); err != nil {
-				out.Close()
-				Log.Error("Failed to write "+ass.Name+":", err)
-				retErr = err
-				return
-			}
-			out.Close()
+	if err := swapInstall(distDir, stageDir, backupDir); err != nil {
+		return err
+	}
 
-			_ = FixOwnership(filePath)
-		}
+	if err := cache.save(assetCachePath(distDir)); err != nil {
+		Log.Warn("Failed to persist asset cache:", err)
 	}
 
-	InstalledHash = LatestHash
-	return
+	return nil
+}
+
+// swapInstall atomically replaces distDir with stageDir: it backs up any
+// existing install to backupDir, moves stageDir into place, then removes the
+// backup. On failure to rename stageDir in, it rolls the backup back.
+func swapInstall(distDir, stageDir, backupDir string) error {
+	_ = os.RemoveAll(backupDir)
+	if err := os.Rename(distDir, backupDir); err != nil && !os.IsNotExist(err) {
+		Log.Error("Failed to back up existing install:", err)
+		return err
+	}
+	if err := os.Rename(stageDir, distDir); err != nil {
+		Log.Error("Failed to install staged build:", err)
+		_ = os.Rename(backupDir, distDir)
+		return err
+	}
+	os.RemoveAll(backupDir)
+	return nil
 }
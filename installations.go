@@ -0,0 +1,303 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Velocity Installer, a cross platform gui/cli app for installing Velocity
+ * Copyright (c) 2025 Velocitcs and Velocity contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DiscordVariant identifies which Discord build an Installation patches.
+type DiscordVariant string
+
+const (
+	VariantStable  DiscordVariant = "stable"
+	VariantPTB     DiscordVariant = "ptb"
+	VariantCanary  DiscordVariant = "canary"
+	VariantDev     DiscordVariant = "dev"
+	VariantFlatpak DiscordVariant = "flatpak"
+)
+
+// Installation tracks one patched Discord install: where it lives, which
+// Discord build it is, and what was last installed into it. Borrowed from
+// ficsit-cli's Installations model so patching one variant (say, Canary) never
+// clobbers what's known about another (say, Stable).
+type Installation struct {
+	Path          string         `json:"path"`
+	Variant       DiscordVariant `json:"variant"`
+	InstalledHash string         `json:"installedHash"`
+	BranchTrack   ReleaseTrack   `json:"branchTrack"`
+
+	// latestRelease and latestHash are the release InitGithubDownloader last
+	// fetched for this installation's BranchTrack, carried here (rather than in
+	// a package global) so each tracked installation can show its own
+	// installed-vs-latest status independently. Not persisted: refetched every
+	// time InitGithubDownloader runs.
+	latestRelease *GithubRelease
+	latestHash    string
+}
+
+// InstallationStore is the schema of installations.json.
+type InstallationStore struct {
+	SelectedInstallation string         `json:"selectedInstallation"`
+	Installations        []Installation `json:"installations"`
+}
+
+var Installations = InstallationStore{}
+
+var installationFlag = flag.String("installation", "", "name of the tracked Discord installation to operate on, e.g. DiscordCanary")
+
+func installationsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "VelocityInstaller", "installations.json"), nil
+}
+
+// LoadInstallations reads installations.json, auto-discovers any Discord install
+// on disk that isn't already tracked, and applies --installation. Call after
+// flag.Parse() and after LoadConfig, since newly discovered installations default
+// to Config.VelocityTrack.
+func LoadInstallations() error {
+	if p, err := installationsPath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &Installations)
+		}
+	}
+
+	for _, candidate := range DiscoverInstallations() {
+		if FindInstallation(candidate.Path) == nil {
+			Installations.Installations = append(Installations.Installations, candidate)
+		}
+	}
+
+	if Installations.SelectedInstallation == "" && len(Installations.Installations) > 0 {
+		Installations.SelectedInstallation = Installations.Installations[0].Path
+	}
+
+	if *installationFlag != "" {
+		if _, err := SelectInstallationByName(*installationFlag); err != nil {
+			Log.Warn("Failed to select installation:", err)
+		}
+	}
+
+	return SaveInstallations()
+}
+
+// SaveInstallations persists the current installation list and selection.
+func SaveInstallations() error {
+	p, err := installationsPath()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(Installations, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, b, 0644)
+}
+
+// FindInstallation returns the tracked Installation at installPath, or nil.
+func FindInstallation(installPath string) *Installation {
+	for i := range Installations.Installations {
+		if Installations.Installations[i].Path == installPath {
+			return &Installations.Installations[i]
+		}
+	}
+	return nil
+}
+
+// GetSelectedInstallation returns the Installation named by
+// Installations.SelectedInstallation, or nil if none is selected or it can no
+// longer be found.
+func GetSelectedInstallation() *Installation {
+	if Installations.SelectedInstallation == "" {
+		return nil
+	}
+	return FindInstallation(Installations.SelectedInstallation)
+}
+
+// SelectInstallationByName finds the first tracked Installation with a directory
+// named name above its resources/app Path (e.g. "DiscordCanary" for
+// /usr/share/DiscordCanary/resources/app, matching --installation) and makes it
+// the selection.
+func SelectInstallationByName(name string) (*Installation, error) {
+	for i := range Installations.Installations {
+		inst := &Installations.Installations[i]
+		for dir := path.Dir(path.Dir(inst.Path)); dir != path.Dir(dir); dir = path.Dir(dir) {
+			if path.Base(dir) == name {
+				Installations.SelectedInstallation = inst.Path
+				return inst, SaveInstallations()
+			}
+		}
+	}
+	return nil, fmt.Errorf("no tracked installation named %q", name)
+}
+
+// DiscoverInstallations scans the well-known per-platform Discord locations for
+// installs that could host Velocity. Discovered installs default to
+// Config.VelocityTrack; LoadInstallations won't re-add one already tracked.
+//
+// Installation.Path is always the resources/app directory Velocity patches
+// main.js into, not the top-level Discord install directory - installLatestBuilds
+// and InitGithubDownloader both resolve main.js relative to it.
+func DiscoverInstallations() []Installation {
+	switch runtime.GOOS {
+	case "linux":
+		return discoverLinuxInstallations()
+	case "darwin":
+		return discoverDarwinInstallations()
+	case "windows":
+		return discoverWindowsInstallations()
+	default:
+		return nil
+	}
+}
+
+func discoverLinuxInstallations() []Installation {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = ""
+	}
+
+	var found []Installation
+	for _, name := range LinuxDiscordNames {
+		candidates := []string{
+			path.Join("/usr/share", name),
+			path.Join("/opt", name),
+			path.Join("/var/lib/flatpak/app", name, "current/active/files/discord"),
+		}
+		if home != "" {
+			candidates = append(candidates, path.Join(home, ".local/share/flatpak/app", name, "current/active/files/discord"))
+		}
+
+		for _, dir := range candidates {
+			if stat, err := os.Stat(dir); err == nil && stat.IsDir() {
+				found = append(found, Installation{Path: resourcesAppDir(dir), Variant: variantForName(name), InstalledHash: "None", BranchTrack: Config.VelocityTrack})
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+func discoverDarwinInstallations() []Installation {
+	candidates := map[string]DiscordVariant{
+		"/Applications/Discord.app":        VariantStable,
+		"/Applications/Discord PTB.app":    VariantPTB,
+		"/Applications/Discord Canary.app": VariantCanary,
+	}
+
+	var found []Installation
+	for dir, variant := range candidates {
+		if stat, err := os.Stat(dir); err == nil && stat.IsDir() {
+			found = append(found, Installation{Path: path.Join(dir, "Contents", "Resources", "app"), Variant: variant, InstalledHash: "None", BranchTrack: Config.VelocityTrack})
+		}
+	}
+
+	return found
+}
+
+func discoverWindowsInstallations() []Installation {
+	appData := os.Getenv("LOCALAPPDATA")
+	if appData == "" {
+		return nil
+	}
+
+	candidates := map[string]DiscordVariant{
+		"Discord":       VariantStable,
+		"DiscordPTB":    VariantPTB,
+		"DiscordCanary": VariantCanary,
+	}
+
+	var found []Installation
+	for name, variant := range candidates {
+		dir := path.Join(appData, name)
+		if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+			continue
+		}
+
+		versionDir, err := latestVersionedDir(dir, "app-")
+		if err != nil {
+			Log.Debug("Found Discord install at", dir, "but no app-* version folder yet:", err)
+			continue
+		}
+
+		found = append(found, Installation{Path: path.Join(versionDir, "resources", "app"), Variant: variant, InstalledHash: "None", BranchTrack: Config.VelocityTrack})
+	}
+
+	return found
+}
+
+// latestVersionedDir returns the most recently modified subdirectory of parent
+// whose name starts with prefix, e.g. the current "app-1.2.3" under a Windows
+// Discord install's %LOCALAPPDATA%\Discord, which Discord's own updater
+// replaces with a new "app-X.Y.Z" directory on every update.
+func latestVersionedDir(parent, prefix string) (string, error) {
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return "", err
+	}
+
+	var best string
+	var bestMod time.Time
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if best == "" || info.ModTime().After(bestMod) {
+			best = e.Name()
+			bestMod = info.ModTime()
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("no %s* directory found in %s", prefix, parent)
+	}
+
+	return path.Join(parent, best), nil
+}
+
+// resourcesAppDir returns the resources/app directory Velocity patches main.js
+// into, given a top-level Discord install directory installDir.
+func resourcesAppDir(installDir string) string {
+	return path.Join(installDir, "resources", "app")
+}
+
+func variantForName(name string) DiscordVariant {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(lower, "com.discordapp."):
+		return VariantFlatpak
+	case strings.Contains(lower, "ptb"):
+		return VariantPTB
+	case strings.Contains(lower, "canary"):
+		return VariantCanary
+	case strings.Contains(lower, "development"):
+		return VariantDev
+	default:
+		return VariantStable
+	}
+}
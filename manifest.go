@@ -0,0 +1,131 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Velocity Installer, a cross platform gui/cli app for installing Velocity
+ * Copyright (c) 2025 Velocitcs and Velocity contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"velocityinstaller/buildinfo"
+)
+
+const ManifestAssetName = "SHA256SUMS"
+const ManifestSignatureAssetName = "SHA256SUMS.sig"
+
+var ErrManifestAssetMissing = errors.New("release does not contain a signed SHA256SUMS manifest")
+var ErrChecksumMismatch = errors.New("downloaded asset does not match the signed manifest")
+
+// ReleaseManifest maps an asset filename to its expected lowercase hex SHA-256 hash.
+type ReleaseManifest map[string]string
+
+// FetchReleaseManifest downloads the SHA256SUMS manifest and its detached SHA256SUMS.sig
+// from release, verifies the signature against buildinfo.UpdatePublicKey, and returns the
+// expected hash for every asset it lists. Callers must treat a failure here as fatal and
+// must not install any asset from release without a verified manifest.
+func FetchReleaseManifest(release *GithubRelease) (ReleaseManifest, error) {
+	manifestURL := findAssetURL(release, ManifestAssetName)
+	sigURL := findAssetURL(release, ManifestSignatureAssetName)
+	if manifestURL == "" || sigURL == "" {
+		return nil, ErrManifestAssetMissing
+	}
+
+	manifestBytes, err := downloadBytes(manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ManifestAssetName, err)
+	}
+
+	sig, err := downloadBytes(sigURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ManifestSignatureAssetName, err)
+	}
+
+	if len(buildinfo.UpdatePublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("embedded update public key is %d bytes, want %d (binary likely built without -X buildinfo.UpdatePublicKey)", len(buildinfo.UpdatePublicKey), ed25519.PublicKeySize)
+	}
+
+	if !ed25519.Verify(buildinfo.UpdatePublicKey, manifestBytes, sig) {
+		return nil, errors.New("signature verification failed for release manifest")
+	}
+
+	return parseManifest(manifestBytes), nil
+}
+
+func parseManifest(b []byte) ReleaseManifest {
+	manifest := make(ReleaseManifest)
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		// Standard `sha256sum` output: "<hash>  <filename>"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		manifest[fields[1]] = strings.ToLower(fields[0])
+	}
+
+	return manifest
+}
+
+// VerifyChecksum checks hexHash, the hash of a downloaded file named assetName, against
+// the expected hash recorded in manifest.
+func VerifyChecksum(manifest ReleaseManifest, assetName, hexHash string) error {
+	expected, ok := manifest[assetName]
+	if !ok {
+		return fmt.Errorf("%s: %w", assetName, ErrManifestAssetMissing)
+	}
+
+	if !strings.EqualFold(expected, hexHash) {
+		return fmt.Errorf("%s: %w", assetName, ErrChecksumMismatch)
+	}
+
+	return nil
+}
+
+// hashingCopy copies src into dst while computing its SHA-256 hash, returning the hash
+// as lowercase hex. Used to checksum a download without buffering it twice.
+func hashingCopy(dst io.Writer, src io.Reader) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hasher), src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func findAssetURL(release *GithubRelease, name string) string {
+	if ass := findAsset(release, name); ass != nil {
+		return ass.DownloadURL
+	}
+	return ""
+}
+
+func downloadBytes(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return nil, errors.New(res.Status)
+	}
+
+	return io.ReadAll(res.Body)
+}
@@ -9,11 +9,11 @@ package main
 import (
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path"
 	"runtime"
+	"strconv"
 	"time"
 	"velocityinstaller/buildinfo"
 )
@@ -21,40 +21,129 @@ import (
 var IsSelfOutdated = false
 var SelfUpdateCheckDoneChan = make(chan bool, 1)
 
+// LatestInstallerRelease is the release UpdateSelf will install, as selected by
+// Config.UpdateTrack (or Config.PinnedVersion) the last time init() ran.
+var LatestInstallerRelease *GithubRelease
+
+// buildTime is this binary's build timestamp, baked in via
+// `-ldflags "-X velocityinstaller/buildinfo.BuildTimeUnix=..."`. It's the zero
+// time for non-release builds, where buildinfo.BuildTimeUnix is unset.
+var buildTime = parseBuildTime()
+
+func parseBuildTime() time.Time {
+	sec, err := strconv.ParseInt(buildinfo.BuildTimeUnix, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
 func init() {
 	if buildinfo.InstallerTag == buildinfo.VersionUnknown {
 		Log.Debug("Disabling self updater because this is not a release build")
 		return
 	}
 
+	LoadConfig()
+
 	go DeleteOldExecutable()
 
 	go func() {
-		Log.Debug("Checking for Installer Updates...")
+		Log.Debug("Checking for Installer Updates on track", Config.UpdateTrack)
 
-		res, err := GetGithubRelease(InstallerReleaseUrl)
+		releases, err := GetGithubReleases(InstallerReleaseUrl)
 		if err != nil {
 			Log.Warn("Failed to check for self updates:", err)
 			SelfUpdateCheckDoneChan <- false
 			return
 		}
 
-		IsSelfOutdated = res.TagName != buildinfo.InstallerTag
+		res := SelectReleaseForTrack(releases, Config.UpdateTrack, Config.PinnedVersion)
+		if res == nil {
+			Log.Warn("No installer release found for track", Config.UpdateTrack)
+			SelfUpdateCheckDoneChan <- false
+			return
+		}
+
+		LatestInstallerRelease = res
+		IsSelfOutdated = isNewerRelease(res)
 		Log.Debug("Is self outdated?", IsSelfOutdated)
 		SelfUpdateCheckDoneChan <- true
 	}()
 }
 
-func GetInstallerDownloadLink() string {
-	const BaseUrl = "https://github.com/Velocitcy/Installer/releases/latest/download/"
+// republishTolerance is how far past buildTime a same-tag release's
+// published_at must land before we treat it as a republish rather than noise.
+// CI publishes a release a few minutes after compiling the binary it contains,
+// so the release we're currently running always has publishedAt slightly after
+// buildTime; without this tolerance that gap alone would flag every up-to-date
+// install as outdated.
+const republishTolerance = 30 * time.Minute
+
+// isNewerRelease reports whether res should replace the running build. A
+// changed tag is always newer. A same-tag release only counts as newer if
+// it was republished well after this binary was built (e.g. a hotfixed
+// security release re-pushed under the same tag to point at a new commit) —
+// otherwise the ordinary CI build-then-publish delay would make the release
+// we're currently running look newer than itself. published_at failing to
+// parse is the only case we fall back to the tag comparison.
+func isNewerRelease(res *GithubRelease) bool {
+	if res.TagName != buildinfo.InstallerTag {
+		return true
+	}
+
+	publishedAt, err := res.PublishedTime()
+	if err != nil {
+		Log.Warn("Failed to parse release published_at, falling back to tag comparison:", err)
+		return false
+	}
+
+	return publishedAt.After(buildTime.Add(republishTolerance))
+}
+
+// UpdateReleaseAge returns a human string like "released 3 hours ago" for
+// LatestInstallerRelease, for the GUI update banner. Returns "" if no release
+// has been resolved yet or its timestamp can't be parsed.
+func UpdateReleaseAge() string {
+	if LatestInstallerRelease == nil {
+		return ""
+	}
+
+	publishedAt, err := LatestInstallerRelease.PublishedTime()
+	if err != nil {
+		return ""
+	}
+
+	hours := int(time.Since(publishedAt).Hours())
+	switch {
+	case hours < 1:
+		return "released less than an hour ago"
+	case hours < 48:
+		return fmt.Sprintf("released %d hours ago", hours)
+	default:
+		return fmt.Sprintf("released %d days ago", hours/24)
+	}
+}
+
+// GetInstallerDownloadLink returns the browser_download_url of the installer
+// asset matching this platform within release, or "" if release doesn't carry
+// one (e.g. an older release predating that asset).
+func GetInstallerDownloadLink(release *GithubRelease) string {
+	filename := installerAssetName()
+	if filename == "" {
+		return ""
+	}
+	return findAssetURL(release, filename)
+}
+
+func installerAssetName() string {
 	switch runtime.GOOS {
 	case "windows":
-		filename := Ternary(buildinfo.UiType == buildinfo.UiTypeCli, "VelocityInstallerCli.exe", "VelocityInstaller.exe")
-		return BaseUrl + filename
+		return Ternary(buildinfo.UiType == buildinfo.UiTypeCli, "VelocityInstallerCli.exe", "VelocityInstaller.exe")
 	case "darwin":
-		return BaseUrl + "VelocityInstaller.MacOS.zip"
+		return "VelocityInstaller.MacOS.zip"
 	case "linux":
-		return BaseUrl + "VelocityInstallerCli-linux"
+		return "VelocityInstallerCli-linux"
 	default:
 		return ""
 	}
@@ -69,13 +158,46 @@ func UpdateSelf() error {
 		return errors.New("cannot update self. Either no update available or macos")
 	}
 
-	url := GetInstallerDownloadLink()
+	if LatestInstallerRelease == nil {
+		return errors.New("no installer release information available")
+	}
+
+	return updateSelfFromRelease(LatestInstallerRelease)
+}
+
+// UpdateSelfToVersion installs the installer release tagged tag, bypassing
+// Config.UpdateTrack. Used by --update-version and the GUI's manual version pin.
+func UpdateSelfToVersion(tag string) error {
+	if runtime.GOOS == "darwin" {
+		return errors.New("cannot update self on macos")
+	}
+
+	releases, err := GetGithubReleases(InstallerReleaseUrl)
+	if err != nil {
+		return fmt.Errorf("failed to list installer releases: %w", err)
+	}
+
+	release := findReleaseByTag(releases, tag)
+	if release == nil {
+		return fmt.Errorf("no installer release found with tag %q", tag)
+	}
+
+	return updateSelfFromRelease(release)
+}
+
+func updateSelfFromRelease(release *GithubRelease) error {
+	url := GetInstallerDownloadLink(release)
 	if url == "" {
 		return errors.New("failed to get installer download link")
 	}
 
 	Log.Debug("Updating self from", url)
 
+	manifest, err := FetchReleaseManifest(release)
+	if err != nil {
+		return fmt.Errorf("refusing to self update without a verified manifest: %w", err)
+	}
+
 	ownExePath, err := os.Executable()
 	if err != nil {
 		return err
@@ -102,7 +224,8 @@ func UpdateSelf() error {
 		return fmt.Errorf("failed to chmod 755 %s: %w", tmp.Name(), err)
 	}
 
-	if _, err = io.Copy(tmp, res.Body); err != nil {
+	hash, err := hashingCopy(tmp, res.Body)
+	if err != nil {
 		return err
 	}
 
@@ -110,6 +233,10 @@ func UpdateSelf() error {
 		return err
 	}
 
+	if err = VerifyChecksum(manifest, path.Base(url), hash); err != nil {
+		return fmt.Errorf("refusing to install update: %w", err)
+	}
+
 	if err = os.Remove(ownExePath); err != nil {
 		if err = os.Rename(ownExePath, ownExePath+".old"); err != nil {
 			return fmt.Errorf("failed to remove or rename own executable: %w", err)